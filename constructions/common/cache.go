@@ -0,0 +1,169 @@
+package common
+
+import (
+	"sync"
+
+	"github.com/OpenWhiteBox/AES/primitives/encoding"
+	"github.com/OpenWhiteBox/AES/primitives/matrix"
+)
+
+// numShards is the number of shards each cache is split into, to reduce lock contention when RandomSource is shared
+// across goroutines.  Sharding on the label's first byte spreads labels roughly evenly without needing a smarter hash.
+const numShards = 16
+
+// shardFor picks a shard for the given cache key, based on its first byte.
+func shardFor(key [16]byte) int {
+	return int(key[0]) % numShards
+}
+
+// shuffleCache is a sharded, concurrency-safe map from label to generated shuffle.
+type shuffleCache [numShards]struct {
+	mu sync.RWMutex
+	m  map[[16]byte]encoding.Shuffle
+}
+
+func newShuffleCache() *shuffleCache {
+	c := new(shuffleCache)
+	for i := range c {
+		c[i].m = make(map[[16]byte]encoding.Shuffle)
+	}
+
+	return c
+}
+
+func (c *shuffleCache) get(key [16]byte) (encoding.Shuffle, bool) {
+	shard := &c[shardFor(key)]
+
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	v, ok := shard.m[key]
+	return v, ok
+}
+
+func (c *shuffleCache) set(key [16]byte, v encoding.Shuffle) {
+	shard := &c[shardFor(key)]
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	shard.m[key] = v
+}
+
+// snapshot returns a plain copy of every entry in the cache, for serialization.
+func (c *shuffleCache) snapshot() map[[16]byte]encoding.Shuffle {
+	out := make(map[[16]byte]encoding.Shuffle)
+
+	for i := range c {
+		c[i].mu.RLock()
+		for k, v := range c[i].m {
+			out[k] = v
+		}
+		c[i].mu.RUnlock()
+	}
+
+	return out
+}
+
+// load merges the entries of m into the cache, overwriting any existing entries with the same key.  It takes each
+// shard's lock once, rather than once per key.
+func (c *shuffleCache) load(m map[[16]byte]encoding.Shuffle) {
+	var byShard [numShards][]shuffleCacheEntry
+	for k, v := range m {
+		shard := shardFor(k)
+		byShard[shard] = append(byShard[shard], shuffleCacheEntry{k, v})
+	}
+
+	for i, entries := range byShard {
+		if len(entries) == 0 {
+			continue
+		}
+
+		c[i].mu.Lock()
+		for _, e := range entries {
+			c[i].m[e.key] = e.value
+		}
+		c[i].mu.Unlock()
+	}
+}
+
+type shuffleCacheEntry struct {
+	key   [16]byte
+	value encoding.Shuffle
+}
+
+// matrixCache is a sharded, concurrency-safe map from label to generated matrix.
+type matrixCache [numShards]struct {
+	mu sync.RWMutex
+	m  map[[16]byte]matrix.Matrix
+}
+
+func newMatrixCache() *matrixCache {
+	c := new(matrixCache)
+	for i := range c {
+		c[i].m = make(map[[16]byte]matrix.Matrix)
+	}
+
+	return c
+}
+
+func (c *matrixCache) get(key [16]byte) (matrix.Matrix, bool) {
+	shard := &c[shardFor(key)]
+
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	v, ok := shard.m[key]
+	return v, ok
+}
+
+func (c *matrixCache) set(key [16]byte, v matrix.Matrix) {
+	shard := &c[shardFor(key)]
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	shard.m[key] = v
+}
+
+// snapshot returns a plain copy of every entry in the cache, for serialization.
+func (c *matrixCache) snapshot() map[[16]byte]matrix.Matrix {
+	out := make(map[[16]byte]matrix.Matrix)
+
+	for i := range c {
+		c[i].mu.RLock()
+		for k, v := range c[i].m {
+			out[k] = v
+		}
+		c[i].mu.RUnlock()
+	}
+
+	return out
+}
+
+// load merges the entries of m into the cache, overwriting any existing entries with the same key.  It takes each
+// shard's lock once, rather than once per key.
+func (c *matrixCache) load(m map[[16]byte]matrix.Matrix) {
+	var byShard [numShards][]matrixCacheEntry
+	for k, v := range m {
+		shard := shardFor(k)
+		byShard[shard] = append(byShard[shard], matrixCacheEntry{k, v})
+	}
+
+	for i, entries := range byShard {
+		if len(entries) == 0 {
+			continue
+		}
+
+		c[i].mu.Lock()
+		for _, e := range entries {
+			c[i].m[e.key] = e.value
+		}
+		c[i].mu.Unlock()
+	}
+}
+
+type matrixCacheEntry struct {
+	key   [16]byte
+	value matrix.Matrix
+}
@@ -0,0 +1,26 @@
+package common
+
+// MixEntropy stirs extra entropy (a hardware RNG, user input, a second seed, ...) into rs, without invalidating any
+// shuffles or matrices it has already generated and cached.  Only subsequent, not-yet-cached labels are affected,
+// since they're the only ones that still need to call back into the PRF.
+//
+// This mirrors Tendermint's MixEntropy: the new seed is SHA-256(oldSeed || extra) (its first 16 bytes for the
+// AES-CTR backend, all 32 for ChaCha20), so mixing is a one-way operation
+// an attacker who only observes later output can't invert to recover extra.
+func (rs *RandomSource) MixEntropy(extra []byte) {
+	rs.prfMu.Lock()
+	defer rs.prfMu.Unlock()
+
+	rs.prf = rs.prf.Mix(extra)
+}
+
+// Ratchet hashes rs's PRF seed forward (seed = SHA-256(seed), truncated as in MixEntropy) so that anything derived from the old seed--other than
+// what's already cached--can no longer be reproduced, even by someone who later learns the new seed.  This gives
+// forward secrecy across construction phases, e.g. between generating public label-derived tables and secret
+// round-key-derived tables from the same RandomSource.
+func (rs *RandomSource) Ratchet() {
+	rs.prfMu.Lock()
+	defer rs.prfMu.Unlock()
+
+	rs.prf = rs.prf.Ratchet()
+}
@@ -0,0 +1,65 @@
+package common
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestMixEntropyPreservesCacheButChangesFreshOutput(t *testing.T) {
+	rs := NewRandomSource(testName, testSeed)
+
+	cachedLabel := testLabel("mix-entropy-cached")
+	freshLabel := testLabel("mix-entropy-fresh")
+
+	shuffleBefore := rs.Shuffle(cachedLabel)
+	matrixBefore := rs.Matrix(cachedLabel, 32)
+	streamBefore := readN(t, rs.Stream(freshLabel), 64)
+
+	rs.MixEntropy([]byte("hardware-rng-sample"))
+
+	shuffleAfter := rs.Shuffle(cachedLabel)
+	matrixAfter := rs.Matrix(cachedLabel, 32)
+	streamAfter := readN(t, rs.Stream(freshLabel), 64)
+
+	if !reflect.DeepEqual(shuffleBefore, shuffleAfter) {
+		t.Errorf("MixEntropy changed a cached Shuffle result")
+	}
+	if !reflect.DeepEqual(matrixBefore, matrixAfter) {
+		t.Errorf("MixEntropy changed a cached Matrix result")
+	}
+	if bytes.Equal(streamBefore, streamAfter) {
+		t.Errorf("MixEntropy did not change the stream for a label that wasn't already cached")
+	}
+}
+
+func TestRatchetChangesOutput(t *testing.T) {
+	rs := NewRandomSource(testName, testSeed)
+
+	label := testLabel("ratchet-label")
+
+	before := readN(t, rs.Stream(label), 64)
+	rs.Ratchet()
+	after := readN(t, rs.Stream(label), 64)
+
+	if bytes.Equal(before, after) {
+		t.Errorf("Ratchet did not change the stream output")
+	}
+}
+
+// TestRatchetIsNotInvertibleViaSeed checks that, after Ratchet, re-deriving a RandomSource from the original seed no
+// longer reproduces rs's current output: Ratchet moves the PRF off the original seed rather than leaving it in place.
+func TestRatchetIsNotInvertibleViaSeed(t *testing.T) {
+	rs := NewRandomSource(testName, testSeed)
+	label := testLabel("ratchet-forward-secrecy")
+
+	rs.Ratchet()
+	ratcheted := readN(t, rs.Stream(label), 64)
+
+	fromOriginalSeed := NewRandomSource(testName, testSeed)
+	notRatcheted := readN(t, fromOriginalSeed.Stream(label), 64)
+
+	if bytes.Equal(ratcheted, notRatcheted) {
+		t.Errorf("a fresh source built from the original seed reproduced the ratcheted source's output")
+	}
+}
@@ -0,0 +1,131 @@
+package common
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"reflect"
+
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/OpenWhiteBox/AES/primitives/encoding"
+	"github.com/OpenWhiteBox/AES/primitives/matrix"
+)
+
+// cacheHKDFInfo is the fixed HKDF info string used to derive a cache's encryption key from its RandomSource's seed.
+// Bumping it invalidates every previously marshaled cache, by design.
+const cacheHKDFInfo = "OpenWhiteBox/AES RandomSource cache v1"
+
+// cacheSnapshot is the plaintext contents of MarshalCache/UnmarshalCache, gob-encoded before encryption.
+type cacheSnapshot struct {
+	Shuffles map[[16]byte]encoding.Shuffle
+	Matrices map[[16]byte]matrix.Matrix
+}
+
+// cacheKey derives the AES-256-GCM key used to seal a RandomSource's cache, from its PRF's current fingerprint and
+// name.  Using the PRF fingerprint as the HKDF secret and the name in the HKDF info means a cache sealed under one
+// (name, seed) pair, at one point in its MixEntropy/Ratchet history, fails authentication--rather than silently
+// decoding into garbage--if loaded into a RandomSource with a different name, seed, or entropy-mixing history.
+func (rs *RandomSource) cacheKey() ([]byte, error) {
+	rs.prfMu.RLock()
+	fingerprint := rs.prf.Fingerprint()
+	rs.prfMu.RUnlock()
+
+	key := make([]byte, 32)
+
+	kdf := hkdf.New(sha256.New, fingerprint, nil, []byte(cacheHKDFInfo+":"+rs.Name))
+	if _, err := io.ReadFull(kdf, key); err != nil {
+		return nil, fmt.Errorf("common: deriving cache key: %w", err)
+	}
+
+	return key, nil
+}
+
+// MarshalCache serializes rs's shuffle and matrix caches into a compact, authenticated-encrypted blob.  The blob is
+// sealed under a key derived from rs's (name, seed, current PRF fingerprint) via HKDF-SHA256, so UnmarshalCache will
+// refuse to load it into a RandomSource constructed with different values, or one that has since called MixEntropy
+// or Ratchet.
+func (rs *RandomSource) MarshalCache() ([]byte, error) {
+	snapshot := cacheSnapshot{
+		Shuffles: rs.encodingCache.snapshot(),
+		Matrices: rs.matrixCache.snapshot(),
+	}
+
+	var plaintext bytes.Buffer
+	if err := gob.NewEncoder(&plaintext).Encode(snapshot); err != nil {
+		return nil, fmt.Errorf("common: encoding cache: %w", err)
+	}
+
+	// encoding.Shuffle and matrix.Matrix belong to other packages: gob encodes them structurally, and nothing
+	// guarantees every field that matters to them is exported. Decode what we just encoded and compare it back
+	// against the original before we ever encrypt it, so a type gob can't faithfully round-trip is a loud error
+	// here instead of a silently corrupted cache down the line.
+	var roundTripped cacheSnapshot
+	if err := gob.NewDecoder(bytes.NewReader(plaintext.Bytes())).Decode(&roundTripped); err != nil {
+		return nil, fmt.Errorf("common: cache failed self-check decode: %w", err)
+	}
+	if !reflect.DeepEqual(snapshot, roundTripped) {
+		return nil, fmt.Errorf("common: cache failed gob round-trip self-check; encoding.Shuffle or matrix.Matrix " +
+			"may carry state gob can't preserve")
+	}
+
+	key, err := rs.cacheKey()
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := aes.NewCipher(key)
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("common: initializing GCM: %w", err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("common: generating nonce: %w", err)
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext.Bytes(), nil), nil
+}
+
+// UnmarshalCache decrypts and authenticates data (as produced by MarshalCache) and merges its contents into rs's
+// shuffle and matrix caches.  If data was sealed under a different (name, seed) pair, or has been tampered with,
+// authentication fails and an error is returned--rs's caches are left untouched.
+func (rs *RandomSource) UnmarshalCache(data []byte) error {
+	key, err := rs.cacheKey()
+	if err != nil {
+		return err
+	}
+
+	block, _ := aes.NewCipher(key)
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("common: initializing GCM: %w", err)
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return fmt.Errorf("common: cache blob too short")
+	}
+
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return fmt.Errorf("common: cache authentication failed (wrong name/seed, or data was tampered with): %w", err)
+	}
+
+	var snapshot cacheSnapshot
+	if err := gob.NewDecoder(bytes.NewReader(plaintext)).Decode(&snapshot); err != nil {
+		return fmt.Errorf("common: decoding cache: %w", err)
+	}
+
+	rs.encodingCache.load(snapshot.Shuffles)
+	rs.matrixCache.load(snapshot.Matrices)
+
+	return nil
+}
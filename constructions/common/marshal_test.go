@@ -0,0 +1,114 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMarshalCacheRoundtrip(t *testing.T) {
+	labelA := testLabel("marshal-cache-a")
+	labelB := testLabel("marshal-cache-b")
+
+	rs1 := NewRandomSource(testName, testSeed)
+	wantShuffle := rs1.Shuffle(labelA)
+	wantMatrix := rs1.Matrix(labelB, 32)
+
+	data, err := rs1.MarshalCache()
+	if err != nil {
+		t.Fatalf("MarshalCache: %v", err)
+	}
+
+	rs2 := NewRandomSource(testName, testSeed)
+	if err := rs2.UnmarshalCache(data); err != nil {
+		t.Fatalf("UnmarshalCache: %v", err)
+	}
+
+	var keyA, keyB [16]byte
+	copy(keyA[:], labelA)
+	copy(keyB[:], labelB)
+
+	gotShuffle, ok := rs2.encodingCache.get(keyA)
+	if !ok {
+		t.Fatalf("UnmarshalCache did not populate the shuffle cache")
+	}
+	if !reflect.DeepEqual(gotShuffle, wantShuffle) {
+		t.Errorf("restored shuffle doesn't match the original")
+	}
+
+	gotMatrix, ok := rs2.matrixCache.get(keyB)
+	if !ok {
+		t.Fatalf("UnmarshalCache did not populate the matrix cache")
+	}
+	if !reflect.DeepEqual(gotMatrix, wantMatrix) {
+		t.Errorf("restored matrix doesn't match the original")
+	}
+
+	// Shuffle/Matrix should now be served from the restored cache rather than recomputed--either way the values
+	// must agree, since generation is deterministic, but this also exercises the cache-hit path.
+	if got := rs2.Shuffle(labelA); !reflect.DeepEqual(got, wantShuffle) {
+		t.Errorf("rs2.Shuffle(labelA) = %v, want %v", got, wantShuffle)
+	}
+	if got := rs2.Matrix(labelB, 32); !reflect.DeepEqual(got, wantMatrix) {
+		t.Errorf("rs2.Matrix(labelB) = %v, want %v", got, wantMatrix)
+	}
+}
+
+func TestMarshalCacheTamperDetected(t *testing.T) {
+	rs1 := NewRandomSource(testName, testSeed)
+	rs1.Shuffle(testLabel("tamper-label"))
+
+	data, err := rs1.MarshalCache()
+	if err != nil {
+		t.Fatalf("MarshalCache: %v", err)
+	}
+
+	tampered := append([]byte{}, data...)
+	tampered[len(tampered)-1] ^= 0xFF
+
+	rs2 := NewRandomSource(testName, testSeed)
+	if err := rs2.UnmarshalCache(tampered); err == nil {
+		t.Fatalf("UnmarshalCache accepted a tampered blob")
+	}
+
+	var key [16]byte
+	copy(key[:], testLabel("tamper-label"))
+	if _, ok := rs2.encodingCache.get(key); ok {
+		t.Errorf("UnmarshalCache populated the cache despite failing authentication")
+	}
+}
+
+// TestMarshalCacheWrongBackendRejected checks that a cache sealed on one PRF backend doesn't authenticate against a
+// RandomSource with the same name and seed bytes but a different backend--AES-CTR and ChaCha20 PRFs produce
+// different streams from equal seeds, so their fingerprints (and therefore cache keys) must differ too.
+func TestMarshalCacheWrongBackendRejected(t *testing.T) {
+	rs1 := NewRandomSource(testName, testSeed2)
+	rs1.Shuffle(testLabel("wrong-backend-label"))
+
+	data, err := rs1.MarshalCache()
+	if err != nil {
+		t.Fatalf("MarshalCache: %v", err)
+	}
+
+	rs2 := NewRandomSourceWithPRF(testName, testSeed2, NewChaCha20PRF(testName, testSeed2))
+	if err := rs2.UnmarshalCache(data); err == nil {
+		t.Fatalf("UnmarshalCache accepted a cache sealed under a different PRF backend")
+	}
+}
+
+func TestMarshalCacheWrongSeedRejected(t *testing.T) {
+	rs1 := NewRandomSource(testName, testSeed)
+	rs1.Shuffle(testLabel("wrong-seed-label"))
+
+	data, err := rs1.MarshalCache()
+	if err != nil {
+		t.Fatalf("MarshalCache: %v", err)
+	}
+
+	otherSeed := append([]byte{}, testSeed...)
+	otherSeed[0] ^= 0xFF
+
+	rs2 := NewRandomSource(testName, otherSeed)
+	if err := rs2.UnmarshalCache(data); err == nil {
+		t.Fatalf("UnmarshalCache accepted a cache sealed under a different seed")
+	}
+}
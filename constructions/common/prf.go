@@ -0,0 +1,135 @@
+package common
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+
+	"golang.org/x/crypto/chacha20"
+)
+
+// PRF is a pseudo-random function keyed by a seed, producing a keystream and derived sub-keys from a (possibly
+// public) label.  It's the pluggable backend behind RandomSource--swapping the PRF changes how Stream/Shuffle/Matrix
+// derive their randomness without changing their external behavior.
+type PRF interface {
+	// Stream returns a keystream generator for the given label.
+	Stream(label []byte) cipher.Stream
+
+	// SubKey derives a 16-byte key from the label, for callers that need a concrete key rather than a keystream.
+	SubKey(label []byte) []byte
+
+	// Mix folds extra entropy into the PRF's seed and returns the resulting PRF, leaving the receiver untouched.
+	// How the new seed is derived (length, hash construction) is up to the backend.
+	Mix(extra []byte) PRF
+
+	// Ratchet hashes the PRF's seed forward and returns the resulting PRF, leaving the receiver untouched.  Once
+	// ratcheted, the old seed--and anything derived from it that wasn't already cached--is unrecoverable.
+	Ratchet() PRF
+
+	// Fingerprint returns a 16-byte value that identifies the PRF's current key material, without revealing the
+	// seed itself. Two PRFs only ever produce the same fingerprint if they'd produce the same Stream/SubKey output
+	// for every label--in particular, Mix and Ratchet change it. Callers use this to bind derived material (like a
+	// serialized cache) to the exact PRF state it was produced under.
+	Fingerprint() []byte
+}
+
+// aesCTRPRF is the default PRF, matching the AES-CTR construction RandomSource has always used: the label is
+// AES-encrypted under the seed (XORed with the source's name) to produce a sub-key, and that sub-key in turn keys
+// AES-CTR over /dev/null.
+type aesCTRPRF struct {
+	name string
+	seed []byte
+}
+
+// NewAESCTRPRF constructs the default AES-CTR backed PRF.
+func NewAESCTRPRF(name string, seed []byte) PRF {
+	return aesCTRPRF{name, seed}
+}
+
+func (prf aesCTRPRF) SubKey(label []byte) []byte {
+	subKey := make([]byte, 16)
+	c, _ := aes.NewCipher(prf.seed)
+	c.Encrypt(subKey, label)
+
+	for i, b := range []byte(prf.name) {
+		subKey[i] ^= b
+	}
+
+	c.Encrypt(subKey, subKey)
+
+	return subKey
+}
+
+func (prf aesCTRPRF) Stream(label []byte) cipher.Stream {
+	block, _ := aes.NewCipher(prf.SubKey(label))
+	return cipher.NewCTR(block, label)
+}
+
+func (prf aesCTRPRF) Mix(extra []byte) PRF {
+	digest := sha256.Sum256(append(append([]byte{}, prf.seed...), extra...))
+	return aesCTRPRF{prf.name, digest[:16]}
+}
+
+func (prf aesCTRPRF) Ratchet() PRF {
+	digest := sha256.Sum256(prf.seed)
+	return aesCTRPRF{prf.name, digest[:16]}
+}
+
+func (prf aesCTRPRF) Fingerprint() []byte {
+	digest := sha256.Sum256(append(append([]byte("aesctr\x00"+prf.name), 0), prf.seed...))
+	return digest[:16]
+}
+
+// chacha20PRF is a PRF backend built on golang.org/x/crypto/chacha20, useful on platforms without AES-NI where
+// ChaCha20 substantially outperforms AES-CTR.
+type chacha20PRF struct {
+	name string
+	seed []byte // 32-byte ChaCha20 key.
+}
+
+// NewChaCha20PRF constructs a PRF backed by ChaCha20, keyed directly by a 32-byte seed.  As with NewAESCTRPRF, name
+// is mixed into every derivation, so two backends sharing a seed but constructed with different names (the way
+// different components of a whitebox construction are expected to) still produce independent streams.
+func NewChaCha20PRF(name string, seed []byte) PRF {
+	if len(seed) != chacha20.KeySize {
+		panic("NewChaCha20PRF: seed must be 32 bytes")
+	}
+
+	return chacha20PRF{name, seed}
+}
+
+// nonce derives a 12-byte ChaCha20 nonce deterministically from the name and label.
+func (prf chacha20PRF) nonce(label []byte) []byte {
+	digest := sha256.Sum256(append(append([]byte(prf.name), 0), label...))
+	return digest[:chacha20.NonceSize]
+}
+
+func (prf chacha20PRF) SubKey(label []byte) []byte {
+	digest := sha256.Sum256(append(append(append([]byte(prf.name), 0), prf.seed...), label...))
+	return digest[:16]
+}
+
+func (prf chacha20PRF) Stream(label []byte) cipher.Stream {
+	stream, err := chacha20.NewUnauthenticatedCipher(prf.seed, prf.nonce(label))
+	if err != nil {
+		// Only possible if seed/nonce lengths are wrong, which NewChaCha20PRF and nonce already guarantee.
+		panic(err)
+	}
+
+	return stream
+}
+
+func (prf chacha20PRF) Mix(extra []byte) PRF {
+	digest := sha256.Sum256(append(append([]byte{}, prf.seed...), extra...))
+	return chacha20PRF{prf.name, digest[:]}
+}
+
+func (prf chacha20PRF) Ratchet() PRF {
+	digest := sha256.Sum256(prf.seed)
+	return chacha20PRF{prf.name, digest[:]}
+}
+
+func (prf chacha20PRF) Fingerprint() []byte {
+	digest := sha256.Sum256(append(append([]byte("chacha20\x00"+prf.name), 0), prf.seed...))
+	return digest[:16]
+}
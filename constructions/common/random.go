@@ -1,9 +1,10 @@
 package common
 
 import (
-	"crypto/aes"
 	"crypto/cipher"
 	"io"
+	"runtime"
+	"sync"
 
 	"github.com/OpenWhiteBox/AES/primitives/encoding"
 	"github.com/OpenWhiteBox/AES/primitives/matrix"
@@ -21,79 +22,124 @@ func (dn devNull) Read(p []byte) (n int, err error) {
 
 type RandomSource struct {
 	Name string
+
+	// Seed is the seed the RandomSource was constructed with.  It does not track MixEntropy/Ratchet updates to the
+	// underlying PRF, and RandomSource exposes no accessor for the PRF's current key material.
 	Seed []byte
 
-	encodingCache map[[16]byte]encoding.Shuffle
-	matrixCache   map[[16]byte]matrix.Matrix
-}
+	prfMu sync.RWMutex
+	prf   PRF
 
-func NewRandomSource(name string, seed []byte) RandomSource {
-	return RandomSource{
-		name, seed, make(map[[16]byte]encoding.Shuffle), make(map[[16]byte]matrix.Matrix),
-	}
+	encodingCache *shuffleCache
+	matrixCache   *matrixCache
 }
 
-// subKey generates a random key from the context and label that can be used for cryptographic primitives.
-func (rs *RandomSource) subKey(label []byte) []byte {
-	subKey := make([]byte, 16)
-	c, _ := aes.NewCipher(rs.Seed)
-	c.Encrypt(subKey, label)
+// NewRandomSource constructs a RandomSource backed by the default AES-CTR PRF.
+//
+// NewRandomSource returns a *RandomSource, not a RandomSource: the type embeds a sync.RWMutex guarding its PRF state,
+// so copying a RandomSource by value would silently fork that state (and go vet's copylocks check would flag it).
+// Callers should pass the pointer around rather than dereferencing it. (This checkout contains only the common
+// package itself--go build/go vet ./... here turns up no other caller storing or passing RandomSource by value.)
+func NewRandomSource(name string, seed []byte) *RandomSource {
+	return NewRandomSourceWithPRF(name, seed, NewAESCTRPRF(name, seed))
+}
 
-	for i, c := range []byte(rs.Name) {
-		subKey[i] ^= c
+// NewRandomSourceWithPRF constructs a RandomSource backed by an arbitrary PRF, letting callers choose the underlying
+// stream cipher (for example ChaCha20 in place of AES-CTR on platforms without AES-NI).
+func NewRandomSourceWithPRF(name string, seed []byte, prf PRF) *RandomSource {
+	return &RandomSource{
+		Name:          name,
+		Seed:          seed,
+		prf:           prf,
+		encodingCache: newShuffleCache(),
+		matrixCache:   newMatrixCache(),
 	}
-
-	c.Encrypt(subKey, subKey)
-
-	return subKey
 }
 
 // Stream takes a (possibly public) label and produces an io.Reader giving random bytes, useful for deterministically
 // generating random matrices/encodings, in place of (crypto/rand).Reader.
 //
-// It does this by using the seed as an AES key and the label as the IV in CTR mode.  The io.Reader is providing the
-// AES-CTR encryption of /dev/null.
+// It does this by keying the RandomSource's PRF off of the label and reading out its keystream, the equivalent of
+// encrypting /dev/null under that label-dependent keystream.
 func (rs *RandomSource) Stream(label []byte) io.Reader {
-	subKey := rs.subKey(label)
+	rs.prfMu.RLock()
+	prf := rs.prf
+	rs.prfMu.RUnlock()
 
-	// Create pseudo-random byte stream keyed by sub-key.
-	block, _ := aes.NewCipher(subKey)
-	stream := cipher.StreamReader{
-		cipher.NewCTR(block, label),
-		devNull{},
+	return cipher.StreamReader{
+		S: prf.Stream(label),
+		R: devNull{},
 	}
-
-	return stream
 }
 
 // Shuffle takes a (possibly public) label and produces a random shuffle of the integers [0, 16).
+//
+// Shuffle is safe to call concurrently from multiple goroutines.
 func (rs *RandomSource) Shuffle(label []byte) encoding.Shuffle {
 	key := [16]byte{}
 	copy(key[:], label)
 
-	cached, ok := rs.encodingCache[key]
-
-	if ok {
+	if cached, ok := rs.encodingCache.get(key); ok {
 		return cached
-	} else {
-		rs.encodingCache[key] = encoding.GenerateShuffle(rs.Stream(label))
-		return rs.encodingCache[key]
 	}
+
+	shuffle := encoding.GenerateShuffle(rs.Stream(label))
+	rs.encodingCache.set(key, shuffle)
+
+	return shuffle
 }
 
 // Matrix takes a (possibly public) label and produces a random non-singular 128x128 matrix.
+//
+// Matrix is safe to call concurrently from multiple goroutines.
 func (rs *RandomSource) Matrix(label []byte, size int) matrix.Matrix {
 	key := [16]byte{}
 	copy(key[:], label)
 
-	cached, ok := rs.matrixCache[key]
-
-	if ok {
+	if cached, ok := rs.matrixCache.get(key); ok {
 		return cached
-	} else {
-		rs.matrixCache[key] = matrix.GenerateRandom(rs.Stream(label), size)
-		return rs.matrixCache[key]
 	}
+
+	m := matrix.GenerateRandom(rs.Stream(label), size)
+	rs.matrixCache.set(key, m)
+
+	return m
+}
+
+// BatchMatrix generates the matrix for each of labels, fanning the work out across runtime.GOMAXPROCS workers and
+// populating the matrix cache as it goes.  It's meant for whitebox constructions that need many independent tables
+// (e.g. one per round) and would otherwise pay for them one at a time on a single goroutine.
+func (rs *RandomSource) BatchMatrix(labels [][]byte, size int) []matrix.Matrix {
+	out := make([]matrix.Matrix, len(labels))
+
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(labels) {
+		workers = len(labels)
+	}
+
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				out[i] = rs.Matrix(labels[i], size)
+			}
+		}()
+	}
+
+	for i := range labels {
+		jobs <- i
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	return out
 }
 
 // Dirichlet takes a (possibly public) label and produces the output of a uniform dirichlet distribution with `length`
@@ -0,0 +1,116 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/OpenWhiteBox/AES/primitives/encoding"
+	"github.com/OpenWhiteBox/AES/primitives/matrix"
+)
+
+var (
+	testName  = "randomsourcetest"
+	testSeed  = bytes.Repeat([]byte{0x42}, 16)
+	testSeed2 = bytes.Repeat([]byte{0x42}, 32)
+)
+
+// testLabel pads/truncates s to the 16 bytes RandomSource's default AES-CTR backend requires as a CTR IV.
+func testLabel(s string) []byte {
+	label := make([]byte, 16)
+	copy(label, s)
+	return label
+}
+
+// TestBackendsDivergeAcrossButNotWithinThemselves checks part (e) of the RandomSource conformance requirements: two
+// sources on the same backend, name, seed and label agree, but the AES-CTR and ChaCha20 backends disagree with each
+// other under the same name/label even when seeded from the same repeated byte (16 bytes of it for AES-CTR, 32 for
+// ChaCha20).
+func TestBackendsDivergeAcrossButNotWithinThemselves(t *testing.T) {
+	label := testLabel("backend-compare")
+
+	aes1 := NewRandomSource(testName, testSeed)
+	aes2 := NewRandomSource(testName, testSeed)
+
+	chacha1 := NewRandomSourceWithPRF(testName, testSeed2, NewChaCha20PRF(testName, testSeed2))
+	chacha2 := NewRandomSourceWithPRF(testName, testSeed2, NewChaCha20PRF(testName, testSeed2))
+
+	aesOut1, aesOut2 := readN(t, aes1.Stream(label), 256), readN(t, aes2.Stream(label), 256)
+	chachaOut1, chachaOut2 := readN(t, chacha1.Stream(label), 256), readN(t, chacha2.Stream(label), 256)
+
+	if !bytes.Equal(aesOut1, aesOut2) {
+		t.Fatalf("AES-CTR backend isn't deterministic across sources")
+	}
+	if !bytes.Equal(chachaOut1, chachaOut2) {
+		t.Fatalf("ChaCha20 backend isn't deterministic across sources")
+	}
+	if bytes.Equal(aesOut1, chachaOut1) {
+		t.Fatalf("AES-CTR and ChaCha20 backends produced identical output for the same label")
+	}
+}
+
+// TestConcurrentAccess drives BatchMatrix alongside concurrent Shuffle/Matrix calls on a single shared
+// *RandomSource, and checks every result against a single-threaded baseline computed from an independent source with
+// the same (name, seed). Run with `go test -race` to exercise the sharded cache locking this is meant to pin down.
+func TestConcurrentAccess(t *testing.T) {
+	const numLabels = 32
+
+	labels := make([][]byte, numLabels)
+	for i := range labels {
+		labels[i] = testLabel(fmt.Sprintf("race-label-%02d", i))
+	}
+
+	baseline := NewRandomSource(testName, testSeed)
+
+	wantMatrices := make([]matrix.Matrix, numLabels)
+	wantShuffles := make([]encoding.Shuffle, numLabels)
+	for i, label := range labels {
+		wantMatrices[i] = baseline.Matrix(label, 32)
+		wantShuffles[i] = baseline.Shuffle(label)
+	}
+
+	rs := NewRandomSource(testName, testSeed)
+
+	var wg sync.WaitGroup
+	for i := range labels {
+		i := i
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if got := rs.Matrix(labels[i], 32); !reflect.DeepEqual(got, wantMatrices[i]) {
+				t.Errorf("Matrix(%d) raced to a different value than the single-threaded baseline", i)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if got := rs.Shuffle(labels[i]); !reflect.DeepEqual(got, wantShuffles[i]) {
+				t.Errorf("Shuffle(%d) raced to a different value than the single-threaded baseline", i)
+			}
+		}()
+	}
+
+	got := rs.BatchMatrix(labels, 32)
+
+	wg.Wait()
+
+	for i := range labels {
+		if !reflect.DeepEqual(got[i], wantMatrices[i]) {
+			t.Errorf("BatchMatrix[%d] disagreed with the single-threaded baseline", i)
+		}
+	}
+}
+
+func readN(t *testing.T, r io.Reader, n int) []byte {
+	t.Helper()
+
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		t.Fatalf("reading stream: %v", err)
+	}
+
+	return buf
+}
@@ -0,0 +1,151 @@
+// Package randomsourcetest provides a reusable conformance suite for common.RandomSource, modeled on the stream
+// cipher tests in Go's crypto/internal/cryptotest.  It pins down the contract the rest of this repo relies on--
+// determinism, label independence, and cache reproducibility--so changes to RandomSource's internals (concurrency,
+// entropy mixing, PRF backends) can't silently break it.
+package randomsourcetest
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/OpenWhiteBox/AES/constructions/common"
+)
+
+// label16 pads/truncates s to the 16 bytes RandomSource's default AES-CTR backend requires as a CTR IV.
+func label16(s string) []byte {
+	label := make([]byte, 16)
+	copy(label, s)
+	return label
+}
+
+// Stream runs the conformance suite against fresh RandomSources produced by newSource.  newSource must return a
+// RandomSource configured identically (same name, seed and PRF backend) on every call, so that two independently
+// constructed sources are expected to agree.
+func Stream(t *testing.T, newSource func() *common.RandomSource) {
+	t.Run("Deterministic", func(t *testing.T) { testDeterministic(t, newSource) })
+	t.Run("LabelIndependence", func(t *testing.T) { testLabelIndependence(t, newSource) })
+	t.Run("ReadSizeInvariance", func(t *testing.T) { testReadSizeInvariance(t, newSource) })
+	t.Run("CacheReproducibility", func(t *testing.T) { testCacheReproducibility(t, newSource) })
+}
+
+// testDeterministic checks that two independently constructed RandomSources produce byte-identical streams for the
+// same label.
+func testDeterministic(t *testing.T, newSource func() *common.RandomSource) {
+	label := label16("deterministic")
+
+	rs1, rs2 := newSource(), newSource()
+
+	buf1 := make([]byte, 4096)
+	buf2 := make([]byte, 4096)
+
+	if _, err := io.ReadFull(rs1.Stream(label), buf1); err != nil {
+		t.Fatalf("reading from rs1: %v", err)
+	}
+	if _, err := io.ReadFull(rs2.Stream(label), buf2); err != nil {
+		t.Fatalf("reading from rs2: %v", err)
+	}
+
+	if !bytes.Equal(buf1, buf2) {
+		t.Fatalf("same (name, seed, label) produced different streams")
+	}
+}
+
+// testLabelIndependence checks that distinct labels produce statistically uncorrelated streams, by running a
+// chi-square goodness-of-fit test against a uniform distribution over the byte-wise XOR of two labels' streams. If
+// the two streams were correlated, the XOR would be biased away from uniform; if they're independent, XOR of two
+// independent random streams is itself uniform.
+func testLabelIndependence(t *testing.T, newSource func() *common.RandomSource) {
+	const sampleSize = 1 << 20 // 1 MiB, per request.
+
+	rs := newSource()
+
+	bufA := make([]byte, sampleSize)
+	bufB := make([]byte, sampleSize)
+
+	if _, err := io.ReadFull(rs.Stream(label16("independence-a")), bufA); err != nil {
+		t.Fatalf("reading stream A: %v", err)
+	}
+	if _, err := io.ReadFull(rs.Stream(label16("independence-b")), bufB); err != nil {
+		t.Fatalf("reading stream B: %v", err)
+	}
+
+	var counts [256]int
+	for i := range bufA {
+		counts[bufA[i]^bufB[i]]++
+	}
+
+	chiSquare := chiSquareUniform(counts[:], sampleSize)
+
+	// 255 degrees of freedom: mean 255, stddev ~= sqrt(2*255) =~ 22.6. A generous cutoff a good deal further out
+	// than that keeps the test from flaking on two legitimately independent streams while still catching gross
+	// correlation (e.g. one label's stream leaking into the other's).
+	const chiSquareCutoff = 450
+
+	if chiSquare > chiSquareCutoff {
+		t.Fatalf("chi-square statistic %f exceeds cutoff %d; streams for different labels look correlated",
+			chiSquare, chiSquareCutoff)
+	}
+}
+
+// chiSquareUniform computes the chi-square goodness-of-fit statistic for counts (256 categories) against a uniform
+// distribution over total observations.
+func chiSquareUniform(counts []int, total int) float64 {
+	expected := float64(total) / float64(len(counts))
+
+	var stat float64
+	for _, c := range counts {
+		diff := float64(c) - expected
+		stat += diff * diff / expected
+	}
+
+	return stat
+}
+
+// testReadSizeInvariance checks that reading N bytes from a label's stream in one call produces the same bytes as
+// reading them one byte at a time.
+func testReadSizeInvariance(t *testing.T, newSource func() *common.RandomSource) {
+	const n = 4096
+
+	label := label16("read-size-invariance")
+
+	bulk := make([]byte, n)
+	if _, err := io.ReadFull(newSource().Stream(label), bulk); err != nil {
+		t.Fatalf("bulk read: %v", err)
+	}
+
+	byteByByte := make([]byte, n)
+	stream := newSource().Stream(label)
+	for i := range byteByByte {
+		if _, err := io.ReadFull(stream, byteByByte[i:i+1]); err != nil {
+			t.Fatalf("single-byte read at offset %d: %v", i, err)
+		}
+	}
+
+	if !bytes.Equal(bulk, byteByByte) {
+		t.Fatalf("reading in one call disagrees with reading one byte at a time")
+	}
+}
+
+// testCacheReproducibility checks that Shuffle and Matrix agree across the cache boundary: a fresh computation and a
+// cached hit for the same label return the same value.
+func testCacheReproducibility(t *testing.T, newSource func() *common.RandomSource) {
+	label := label16("cache-reproducibility")
+
+	rs := newSource()
+
+	freshShuffle := rs.Shuffle(label)
+	cachedShuffle := rs.Shuffle(label)
+	if !reflect.DeepEqual(freshShuffle, cachedShuffle) {
+		t.Fatalf("Shuffle disagreed across the cache boundary")
+	}
+
+	fresh := newSource()
+	freshMatrix := fresh.Matrix(label, 128)
+	cachedMatrix := fresh.Matrix(label, 128)
+
+	if !reflect.DeepEqual(freshMatrix, cachedMatrix) {
+		t.Fatalf("Matrix disagreed across the cache boundary")
+	}
+}
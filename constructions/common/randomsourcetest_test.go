@@ -0,0 +1,27 @@
+package common_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/OpenWhiteBox/AES/constructions/common"
+	"github.com/OpenWhiteBox/AES/constructions/common/randomsourcetest"
+)
+
+var (
+	conformanceName  = "randomsourcetest"
+	conformanceSeed  = bytes.Repeat([]byte{0x42}, 16)
+	conformanceSeed2 = bytes.Repeat([]byte{0x42}, 32)
+)
+
+func TestAESCTRStream(t *testing.T) {
+	randomsourcetest.Stream(t, func() *common.RandomSource {
+		return common.NewRandomSource(conformanceName, conformanceSeed)
+	})
+}
+
+func TestChaCha20Stream(t *testing.T) {
+	randomsourcetest.Stream(t, func() *common.RandomSource {
+		return common.NewRandomSourceWithPRF(conformanceName, conformanceSeed2, common.NewChaCha20PRF(conformanceName, conformanceSeed2))
+	})
+}